@@ -0,0 +1,147 @@
+package drain
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentDrain shards log lines across N independent Drain
+// instances, hashing a stable key derived from each line to pick a
+// shard, and serializes access to that shard behind its own mutex.
+// This lets goroutines call Log/Match concurrently without every
+// caller wrapping Drain in its own mutex, since a plain Drain's
+// idToCluster, rootNode, and clustersCounter are not safe for
+// concurrent use.
+type ConcurrentDrain struct {
+	shardKeyTokens int
+	shards         []*concurrentDrainShard
+}
+
+type concurrentDrainShard struct {
+	mu         sync.Mutex
+	contention int64
+	drain      *Drain
+}
+
+func (s *concurrentDrainShard) lock() {
+	if !s.mu.TryLock() {
+		atomic.AddInt64(&s.contention, 1)
+		s.mu.Lock()
+	}
+}
+
+func (s *concurrentDrainShard) unlock() {
+	s.mu.Unlock()
+}
+
+// shardIDSpace bounds how many clusters a single shard may hold
+// (1e9) so shard index and per-shard cluster id can be packed into one
+// global id without colliding, both in Clusters() and in OnEvict.
+const shardIDSpace = 1_000_000_000
+
+func globalClusterID(shardIndex, localID int) int {
+	return shardIndex*shardIDSpace + localID
+}
+
+// NewConcurrentDrain builds a ConcurrentDrain with shardCount
+// independent Drain shards, each constructed from its own copy of
+// config. shardKeyTokens caps how many of a line's leading
+// whitespace-delimited tokens are hashed to choose a shard; 0 hashes
+// the whole line. Configure shardKeyTokens to a value shared by lines
+// that must land in the same cluster (e.g. a service name prefix).
+func NewConcurrentDrain(config *Config, shardCount, shardKeyTokens int) *ConcurrentDrain {
+	if shardCount < 1 {
+		panic("shardCount must be at least 1")
+	}
+	cd := &ConcurrentDrain{
+		shardKeyTokens: shardKeyTokens,
+		shards:         make([]*concurrentDrainShard, shardCount),
+	}
+	for i := range cd.shards {
+		shardConfig := *config
+		if config.OnEvict != nil {
+			shardIndex := i
+			shardConfig.OnEvict = func(id int, cluster *LogCluster) {
+				config.OnEvict(globalClusterID(shardIndex, id), cluster)
+			}
+		}
+		cd.shards[i] = &concurrentDrainShard{drain: New(&shardConfig)}
+	}
+	return cd
+}
+
+func (cd *ConcurrentDrain) shardFor(content string) *concurrentDrainShard {
+	key := content
+	if cd.shardKeyTokens > 0 {
+		fields := strings.Fields(content)
+		if len(fields) > cd.shardKeyTokens {
+			fields = fields[:cd.shardKeyTokens]
+		}
+		key = strings.Join(fields, " ")
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return cd.shards[h.Sum32()%uint32(len(cd.shards))]
+}
+
+// Log trains the owning shard on content.
+func (cd *ConcurrentDrain) Log(content string) *LogCluster {
+	shard := cd.shardFor(content)
+	shard.lock()
+	defer shard.unlock()
+	return shard.drain.Log(content)
+}
+
+// Match looks up the best existing cluster for content in its owning
+// shard without training on it.
+func (cd *ConcurrentDrain) Match(content string) *LogCluster {
+	shard := cd.shardFor(content)
+	shard.lock()
+	defer shard.unlock()
+	return shard.drain.Match(content)
+}
+
+// Clusters returns every shard's clusters with IDs renumbered into a
+// single global space (see globalClusterID), since each shard numbers
+// its own clusters independently starting at 1. Each returned
+// LogCluster is an independent copy, safe to keep after the call even
+// if a shard's Log/Evict run concurrently afterward.
+func (cd *ConcurrentDrain) Clusters() []*LogCluster {
+	var merged []*LogCluster
+	for i, shard := range cd.shards {
+		shard.lock()
+		for _, cluster := range shard.drain.Clusters() {
+			renumbered := *cluster
+			renumbered.id = globalClusterID(i, cluster.id)
+			renumbered.samples = cluster.Samples()
+			renumbered.sampleNext = 0
+			merged = append(merged, &renumbered)
+		}
+		shard.unlock()
+	}
+	return merged
+}
+
+// ShardStats reports one shard's cluster count and how many of its
+// lock acquisitions had to wait for a concurrent caller.
+type ShardStats struct {
+	Clusters   int
+	Contention int64
+}
+
+// Stats reports per-shard cluster counts and lock contention, so
+// operators can judge whether to change the shard count.
+func (cd *ConcurrentDrain) Stats() []ShardStats {
+	stats := make([]ShardStats, len(cd.shards))
+	for i, shard := range cd.shards {
+		shard.lock()
+		stats[i] = ShardStats{
+			Clusters:   len(shard.drain.Clusters()),
+			Contention: atomic.LoadInt64(&shard.contention),
+		}
+		shard.unlock()
+	}
+	return stats
+}
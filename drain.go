@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
-	"strings"
+	"time"
 	"unicode"
 
 	"github.com/hashicorp/golang-lru/simplelru"
@@ -18,26 +18,82 @@ type Config struct {
 	ExtraDelimiters []string
 	MaxClusters     int
 	ParamString     string
+	// Tokenizer splits log lines into tokens and joins tokens back into
+	// a line. Defaults to a WhitespaceTokenizer using ExtraDelimiters.
+	Tokenizer LineTokenizer
+	// Metrics, when set, receives pattern-churn counters and gauges as
+	// Drain processes lines. Nil fields are skipped.
+	Metrics *Metrics
+	// OnEvict, when set, is called whenever the cluster cache evicts a
+	// cluster to make room for a new one.
+	OnEvict func(id int, cluster *LogCluster)
+	// MaxAllowedLineLength, when non-zero, truncates token sequences
+	// longer than this before they reach the prefix tree, so a single
+	// pathological line cannot explode tree growth.
+	MaxAllowedLineLength int
+	// Maskers run in order over the raw line before tokenization,
+	// replacing matches with a typed placeholder (see DefaultMaskers).
+	Maskers []MaskRule
+	// SamplesPerCluster, when non-zero, keeps a ring buffer of the most
+	// recent raw lines matched to each cluster, exposed via
+	// LogCluster.Samples.
+	SamplesPerCluster int
 }
 
 type LogCluster struct {
 	logTemplateTokens []string
 	id                int
 	size              int
+	tokenizer         LineTokenizer
+	// FirstSeen and LastSeen are unix-nano timestamps, set by LogAt and
+	// TrainAt and used by Evict to drop idle clusters.
+	FirstSeen  int64
+	LastSeen   int64
+	samples    []string
+	sampleNext int
 }
 
 func (c *LogCluster) getTemplate() string {
-	return strings.Join(c.logTemplateTokens, " ")
+	return c.tokenizer.Join(c.logTemplateTokens)
 }
 func (c *LogCluster) String() string {
 	return fmt.Sprintf("id={%d} : size={%d} : %s", c.id, c.size, c.getTemplate())
 }
 
-func createLogClusterCache(maxSize int) *LogClusterCache {
+// Samples returns the cluster's buffered raw lines, oldest first.
+func (c *LogCluster) Samples() []string {
+	if len(c.samples) == 0 {
+		return nil
+	}
+	out := make([]string, len(c.samples))
+	n := copy(out, c.samples[c.sampleNext:])
+	copy(out[n:], c.samples[:c.sampleNext])
+	return out
+}
+
+func (c *LogCluster) addSample(content string, maxSamples int) {
+	if maxSamples <= 0 {
+		return
+	}
+	if len(c.samples) < maxSamples {
+		c.samples = append(c.samples, content)
+		return
+	}
+	c.samples[c.sampleNext] = content
+	c.sampleNext = (c.sampleNext + 1) % maxSamples
+}
+
+func createLogClusterCache(maxSize int, onEvict func(id int, cluster *LogCluster)) *LogClusterCache {
 	if maxSize == 0 {
 		maxSize = math.MaxInt
 	}
-	cache, _ := simplelru.NewLRU(maxSize, nil)
+	var lruOnEvict simplelru.EvictCallback
+	if onEvict != nil {
+		lruOnEvict = func(key, value interface{}) {
+			onEvict(key.(int), value.(*LogCluster))
+		}
+	}
+	cache, _ := simplelru.NewLRU(maxSize, lruOnEvict)
 	return &LogClusterCache{
 		cache: cache,
 	}
@@ -69,6 +125,10 @@ func (c *LogClusterCache) Get(key int) *LogCluster {
 	return cluster.(*LogCluster)
 }
 
+func (c *LogClusterCache) Remove(key int) {
+	c.cache.Remove(key)
+}
+
 func createNode() *Node {
 	return &Node{
 		keyToChildNode: make(map[string]*Node),
@@ -95,15 +155,25 @@ func New(config *Config) *Drain {
 		panic("depth argument must be at least 3")
 	}
 	config.maxNodeDepth = config.LogClusterDepth - 2
+	if config.Tokenizer == nil {
+		config.Tokenizer = &WhitespaceTokenizer{ExtraDelimiters: config.ExtraDelimiters}
+	}
 
 	d := &Drain{
-		config:      config,
-		rootNode:    createNode(),
-		idToCluster: createLogClusterCache(config.MaxClusters),
+		config:   config,
+		rootNode: createNode(),
 	}
+	d.idToCluster = createLogClusterCache(config.MaxClusters, d.onClusterEvicted)
 	return d
 }
 
+func (d *Drain) onClusterEvicted(id int, cluster *LogCluster) {
+	d.config.Metrics.incPatternsEvicted()
+	if d.config.OnEvict != nil {
+		d.config.OnEvict(id, cluster)
+	}
+}
+
 type Drain struct {
 	config          *Config
 	rootNode        *Node
@@ -111,37 +181,117 @@ type Drain struct {
 	clustersCounter int
 }
 
+// Log trains the parser on content, stamping the matched or newly
+// created cluster with the current time. See LogAt to supply the
+// timestamp of the line yourself (e.g. when replaying older logs).
 func (d *Drain) Log(content string) *LogCluster {
-	contentTokens := d.getContentAsTokens(content)
+	return d.LogAt(content, time.Now().UnixNano())
+}
+
+// Train is an alias for Log.
+func (d *Drain) Train(content string) *LogCluster {
+	return d.LogAt(content, time.Now().UnixNano())
+}
+
+// TrainAt is an alias for LogAt.
+func (d *Drain) TrainAt(content string, ts int64) *LogCluster {
+	return d.LogAt(content, ts)
+}
+
+// LogAt trains the parser on content as having been seen at ts (unix
+// nano), updating the matched cluster's LastSeen and sample buffer.
+func (d *Drain) LogAt(content string, ts int64) *LogCluster {
+	d.config.Metrics.incLinesProcessed()
+
+	rawContent := content
+	if len(d.config.Maskers) > 0 {
+		content = d.applyMaskers(content)
+	}
+	contentTokens := d.config.Tokenizer.Tokenize(content)
+	if d.config.MaxAllowedLineLength > 0 && len(contentTokens) > d.config.MaxAllowedLineLength {
+		contentTokens = contentTokens[:d.config.MaxAllowedLineLength]
+		d.config.Metrics.incLinesTruncated()
+	}
+	d.config.Metrics.observeTokensPerLine(float64(len(contentTokens)))
 
 	matchCluster := d.treeSearch(d.rootNode, contentTokens, d.config.SimTh, false)
 	// Match no existing log cluster
 	if matchCluster == nil {
+		d.config.Metrics.incPatternsDetected()
 		d.clustersCounter++
 		clusterID := d.clustersCounter
 		matchCluster = &LogCluster{
 			logTemplateTokens: contentTokens,
 			id:                clusterID,
 			size:              1,
+			tokenizer:         d.config.Tokenizer,
+			FirstSeen:         ts,
+			LastSeen:          ts,
 		}
+		matchCluster.addSample(rawContent, d.config.SamplesPerCluster)
 		d.idToCluster.Set(clusterID, matchCluster)
 		d.addSeqToPrefixTree(d.rootNode, matchCluster)
 	} else {
 		newTemplateTokens := d.createTemplate(contentTokens, matchCluster.logTemplateTokens)
 		matchCluster.logTemplateTokens = newTemplateTokens
 		matchCluster.size++
+		matchCluster.LastSeen = ts
+		matchCluster.addSample(rawContent, d.config.SamplesPerCluster)
 		// Touch cluster to update its state in the cache.
 		d.idToCluster.Get(matchCluster.id)
 	}
 	return matchCluster
 }
 
-func (d *Drain) getContentAsTokens(content string) []string {
-	content = strings.TrimSpace(content)
-	for _, extraDelimiter := range d.config.ExtraDelimiters {
-		content = strings.Replace(content, extraDelimiter, " ", -1)
+// Match looks up the best existing cluster for content without
+// training on it, or nil if no cluster crosses the similarity
+// threshold. Unlike Log, Match never creates a new cluster.
+func (d *Drain) Match(content string) *LogCluster {
+	if len(d.config.Maskers) > 0 {
+		content = d.applyMaskers(content)
+	}
+	contentTokens := d.config.Tokenizer.Tokenize(content)
+	if d.config.MaxAllowedLineLength > 0 && len(contentTokens) > d.config.MaxAllowedLineLength {
+		contentTokens = contentTokens[:d.config.MaxAllowedLineLength]
+	}
+	return d.treeSearch(d.rootNode, contentTokens, d.config.SimTh, true)
+}
+
+// Evict removes every cluster whose LastSeen predates olderThan (unix
+// nano), prunes their entries from the prefix tree, and returns the
+// evicted clusters. This lets a long-running process shrink the tree
+// proactively instead of relying solely on MaxClusters/LRU pressure.
+func (d *Drain) Evict(olderThan int64) []*LogCluster {
+	var evicted []*LogCluster
+	for _, cluster := range d.idToCluster.Values() {
+		if cluster.LastSeen < olderThan {
+			evicted = append(evicted, cluster)
+		}
+	}
+	for _, cluster := range evicted {
+		// Remove already invokes onClusterEvicted via the cache's
+		// eviction callback; don't call it again here.
+		d.idToCluster.Remove(cluster.id)
+	}
+	if len(evicted) > 0 {
+		d.pruneStaleClusterIDs(d.rootNode)
+	}
+	return evicted
+}
+
+func (d *Drain) pruneStaleClusterIDs(node *Node) {
+	if len(node.clusterIDs) > 0 {
+		liveIDs := make([]int, 0, len(node.clusterIDs))
+		for _, clusterID := range node.clusterIDs {
+			if d.idToCluster.Get(clusterID) != nil {
+				liveIDs = append(liveIDs, clusterID)
+			}
+		}
+		node.clusterIDs = liveIDs
+	}
+	for _, child := range node.keyToChildNode {
+		d.pruneStaleClusterIDs(child)
 	}
-	return strings.Split(content, " ")
 }
 
 func (d *Drain) treeSearch(rootNode *Node, tokens []string, simTh float64, includeParams bool) *LogCluster {
@@ -183,6 +333,7 @@ func (d *Drain) treeSearch(rootNode *Node, tokens []string, simTh float64, inclu
 		}
 		curNodeDepth++
 	}
+	d.config.Metrics.setTreeDepthReached(float64(curNodeDepth))
 
 	// get best match among all clusters with same prefix, or None if no match is above sim_th
 	cluster := d.fastMatch(curNode.clusterIDs, tokens, simTh, includeParams)
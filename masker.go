@@ -0,0 +1,38 @@
+package drain
+
+import "regexp"
+
+// MaskRule replaces every match of Pattern in a raw log line with
+// Placeholder before the line is tokenized. Unlike ParamString
+// wildcards (which only emerge after comparing two clustered lines),
+// masked placeholders are typed up front, so they stay meaningful
+// tokens in a template instead of collapsing to ParamString.
+type MaskRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Placeholder string
+}
+
+func (d *Drain) applyMaskers(content string) string {
+	for _, masker := range d.config.Maskers {
+		content = masker.Pattern.ReplaceAllString(content, masker.Placeholder)
+	}
+	return content
+}
+
+// DefaultMaskers returns a reasonable set of rules for variable types
+// that show up in most logs: IPv4/IPv6 addresses, 0x-prefixed hex
+// numbers, RFC3339 timestamps, UUIDs, MAC addresses, and bare integers.
+// Rules that can appear inside one another (e.g. a timestamp contains
+// integers) are ordered so the more specific pattern masks first.
+func DefaultMaskers() []MaskRule {
+	return []MaskRule{
+		{Name: "timestamp", Pattern: regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})\b`), Placeholder: "<TIMESTAMP>"},
+		{Name: "uuid", Pattern: regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), Placeholder: "<UUID>"},
+		{Name: "mac", Pattern: regexp.MustCompile(`\b(?:[0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}\b`), Placeholder: "<MAC>"},
+		{Name: "ipv4", Pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\b`), Placeholder: "<IP>"},
+		{Name: "ipv6", Pattern: regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){2,7}[0-9a-fA-F]{1,4}\b`), Placeholder: "<IP>"},
+		{Name: "hex", Pattern: regexp.MustCompile(`\b0[xX][0-9a-fA-F]+\b`), Placeholder: "<HEX>"},
+		{Name: "int", Pattern: regexp.MustCompile(`\b\d+\b`), Placeholder: "<NUM>"},
+	}
+}
@@ -0,0 +1,72 @@
+package drain
+
+// Counter, Gauge, and Histogram mirror the subset of the Prometheus
+// client interfaces Drain needs. Real prometheus.Counter/Gauge/Histogram
+// values satisfy them directly, so embedding Drain in a metrics-scraped
+// pipeline doesn't require this package to depend on the Prometheus
+// client library.
+type Counter interface {
+	Inc()
+}
+
+type Gauge interface {
+	Set(float64)
+}
+
+type Histogram interface {
+	Observe(float64)
+}
+
+// Metrics gives operators visibility into pattern churn when Drain is
+// embedded in a long-running pipeline. Any field left nil is simply
+// skipped.
+type Metrics struct {
+	PatternsDetectedTotal Counter
+	PatternsEvictedTotal  Counter
+	LinesProcessedTotal   Counter
+	LinesTruncatedTotal   Counter
+	TokensPerLine         Histogram
+	TreeDepthReached      Gauge
+}
+
+func (m *Metrics) incPatternsDetected() {
+	if m == nil || m.PatternsDetectedTotal == nil {
+		return
+	}
+	m.PatternsDetectedTotal.Inc()
+}
+
+func (m *Metrics) incPatternsEvicted() {
+	if m == nil || m.PatternsEvictedTotal == nil {
+		return
+	}
+	m.PatternsEvictedTotal.Inc()
+}
+
+func (m *Metrics) incLinesProcessed() {
+	if m == nil || m.LinesProcessedTotal == nil {
+		return
+	}
+	m.LinesProcessedTotal.Inc()
+}
+
+func (m *Metrics) incLinesTruncated() {
+	if m == nil || m.LinesTruncatedTotal == nil {
+		return
+	}
+	m.LinesTruncatedTotal.Inc()
+}
+
+func (m *Metrics) observeTokensPerLine(v float64) {
+	if m == nil || m.TokensPerLine == nil {
+		return
+	}
+	m.TokensPerLine.Observe(v)
+}
+
+func (m *Metrics) setTreeDepthReached(v float64) {
+	if m == nil || m.TreeDepthReached == nil {
+		return
+	}
+	m.TreeDepthReached.Set(v)
+}
@@ -0,0 +1,168 @@
+package drain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// snapshotMagic identifies a Drain snapshot stream; snapshotVersion
+// guards against decoding a snapshot written by an incompatible schema.
+const (
+	snapshotMagic   uint32 = 0x44524149 // "DRAI"
+	snapshotVersion uint32 = 1
+)
+
+type snapshotNode struct {
+	Children   map[string]*snapshotNode
+	ClusterIDs []int
+}
+
+func newSnapshotNode(n *Node) *snapshotNode {
+	sn := &snapshotNode{
+		Children:   make(map[string]*snapshotNode, len(n.keyToChildNode)),
+		ClusterIDs: n.clusterIDs,
+	}
+	for key, child := range n.keyToChildNode {
+		sn.Children[key] = newSnapshotNode(child)
+	}
+	return sn
+}
+
+func (sn *snapshotNode) toNode() *Node {
+	n := &Node{
+		keyToChildNode: make(map[string]*Node, len(sn.Children)),
+		clusterIDs:     sn.ClusterIDs,
+	}
+	for key, child := range sn.Children {
+		n.keyToChildNode[key] = child.toNode()
+	}
+	return n
+}
+
+type snapshotCluster struct {
+	ID        int
+	Size      int
+	Tokens    []string
+	FirstSeen int64
+	LastSeen  int64
+	// Samples is ordered oldest to newest.
+	Samples []string
+}
+
+type snapshotData struct {
+	LogClusterDepth int
+	ParamString     string
+	MaxChildren     int
+	// TokenizerName identifies the LineTokenizer the templates were
+	// built with (LineTokenizer.Name), so Restore can reject a config
+	// whose tokenizer would split the same lines differently.
+	TokenizerName   string
+	ClustersCounter int
+	Root            *snapshotNode
+	// Clusters is ordered oldest to newest, matching the LRU recency
+	// order at the time of the snapshot.
+	Clusters []snapshotCluster
+}
+
+// Snapshot serializes the parser's full state - the prefix tree and the
+// cluster cache, including its LRU recency order - so it can later be
+// rebuilt with Restore instead of relearning templates from scratch.
+func (d *Drain) Snapshot(w io.Writer) error {
+	data := &snapshotData{
+		LogClusterDepth: d.config.LogClusterDepth,
+		ParamString:     d.config.ParamString,
+		MaxChildren:     d.config.MaxChildren,
+		TokenizerName:   d.config.Tokenizer.Name(),
+		ClustersCounter: d.clustersCounter,
+		Root:            newSnapshotNode(d.rootNode),
+	}
+	for _, cluster := range d.idToCluster.Values() {
+		data.Clusters = append(data.Clusters, snapshotCluster{
+			ID:        cluster.id,
+			Size:      cluster.size,
+			Tokens:    cluster.logTemplateTokens,
+			FirstSeen: cluster.FirstSeen,
+			LastSeen:  cluster.LastSeen,
+			Samples:   cluster.Samples(),
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.BigEndian, snapshotMagic); err != nil {
+		return fmt.Errorf("drain: write snapshot magic: %w", err)
+	}
+	if err := binary.Write(bw, binary.BigEndian, snapshotVersion); err != nil {
+		return fmt.Errorf("drain: write snapshot version: %w", err)
+	}
+	if err := gob.NewEncoder(bw).Encode(data); err != nil {
+		return fmt.Errorf("drain: encode snapshot: %w", err)
+	}
+	return bw.Flush()
+}
+
+// Restore rebuilds a Drain from a snapshot written by Snapshot. The
+// supplied config's LogClusterDepth, ParamString, and MaxChildren must
+// match the values the snapshot was taken with; Restore rejects any
+// mismatch so clustering behavior cannot silently drift after a reload.
+// Touching a restored cluster during a later Match or Log call preserves
+// the recency order it had when the snapshot was taken.
+func Restore(r io.Reader, config *Config) (*Drain, error) {
+	var magic, version uint32
+	if err := binary.Read(r, binary.BigEndian, &magic); err != nil {
+		return nil, fmt.Errorf("drain: read snapshot magic: %w", err)
+	}
+	if magic != snapshotMagic {
+		return nil, fmt.Errorf("drain: not a drain snapshot (bad magic %#x)", magic)
+	}
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, fmt.Errorf("drain: read snapshot version: %w", err)
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("drain: unsupported snapshot version %d", version)
+	}
+
+	var data snapshotData
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("drain: decode snapshot: %w", err)
+	}
+
+	if data.LogClusterDepth != config.LogClusterDepth {
+		return nil, fmt.Errorf("drain: snapshot LogClusterDepth %d does not match config %d", data.LogClusterDepth, config.LogClusterDepth)
+	}
+	if data.ParamString != config.ParamString {
+		return nil, fmt.Errorf("drain: snapshot ParamString %q does not match config %q", data.ParamString, config.ParamString)
+	}
+	if data.MaxChildren != config.MaxChildren {
+		return nil, fmt.Errorf("drain: snapshot MaxChildren %d does not match config %d", data.MaxChildren, config.MaxChildren)
+	}
+	tokenizer := config.Tokenizer
+	if tokenizer == nil {
+		tokenizer = &WhitespaceTokenizer{ExtraDelimiters: config.ExtraDelimiters}
+	}
+	if data.TokenizerName != tokenizer.Name() {
+		return nil, fmt.Errorf("drain: snapshot tokenizer %q does not match config tokenizer %q", data.TokenizerName, tokenizer.Name())
+	}
+	config.Tokenizer = tokenizer
+
+	d := New(config)
+	d.rootNode = data.Root.toNode()
+	d.clustersCounter = data.ClustersCounter
+	for _, sc := range data.Clusters {
+		cluster := &LogCluster{
+			logTemplateTokens: sc.Tokens,
+			id:                sc.ID,
+			size:              sc.Size,
+			tokenizer:         d.config.Tokenizer,
+			FirstSeen:         sc.FirstSeen,
+			LastSeen:          sc.LastSeen,
+		}
+		for _, sample := range sc.Samples {
+			cluster.addSample(sample, d.config.SamplesPerCluster)
+		}
+		d.idToCluster.Set(sc.ID, cluster)
+	}
+	return d, nil
+}
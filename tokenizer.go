@@ -0,0 +1,105 @@
+package drain
+
+import "strings"
+
+// LineTokenizer splits a raw log line into tokens for clustering and
+// joins tokens back into a line (or template) string. Drain selects an
+// implementation via Config.Tokenizer, allowing different log formats
+// to be split differently without touching the clustering logic. Name
+// identifies the tokenizer's tokenization scheme (not a specific
+// configuration of it); Snapshot/Restore use it to reject restoring a
+// tree built with a different tokenizer.
+type LineTokenizer interface {
+	Tokenize(line string) []string
+	Join(tokens []string) string
+	Name() string
+}
+
+// WhitespaceTokenizer is Drain's original tokenizer: it replaces any
+// configured ExtraDelimiters with spaces and splits on whitespace.
+type WhitespaceTokenizer struct {
+	ExtraDelimiters []string
+}
+
+func (t *WhitespaceTokenizer) Tokenize(line string) []string {
+	line = strings.TrimSpace(line)
+	for _, extraDelimiter := range t.ExtraDelimiters {
+		line = strings.Replace(line, extraDelimiter, " ", -1)
+	}
+	return strings.Split(line, " ")
+}
+
+func (t *WhitespaceTokenizer) Join(tokens []string) string {
+	return strings.Join(tokens, " ")
+}
+
+func (t *WhitespaceTokenizer) Name() string {
+	return "whitespace"
+}
+
+// punctuationSplitRunes are the non-alphanumeric runes (beyond
+// whitespace) that PunctuationTokenizer splits on.
+const punctuationSplitRunes = ",;=:()[]{}/\\|<>@?!"
+
+// PunctuationTokenizer splits on whitespace and most punctuation runes
+// instead of whitespace alone, so dense unstructured lines (JSON-ish
+// fragments, key=value pairs, URLs) break into fine-grained tokens
+// rather than one opaque blob. Quoted substrings are kept intact, and
+// every run of separator runes is itself emitted as a token so Join
+// reconstructs the original line exactly.
+type PunctuationTokenizer struct{}
+
+func (t *PunctuationTokenizer) Tokenize(line string) []string {
+	runes := []rune(line)
+	tokens := make([]string, 0, len(runes)/4+1)
+
+	var word, sep strings.Builder
+	flushWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	flushSep := func() {
+		if sep.Len() > 0 {
+			tokens = append(tokens, sep.String())
+			sep.Reset()
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '"' || r == '\'' {
+			flushWord()
+			flushSep()
+			start := i
+			for i++; i < len(runes) && runes[i] != r; i++ {
+			}
+			if i < len(runes) {
+				i++ // include the closing quote
+			}
+			tokens = append(tokens, string(runes[start:i]))
+			i--
+			continue
+		}
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || strings.ContainsRune(punctuationSplitRunes, r) {
+			flushWord()
+			sep.WriteRune(r)
+			continue
+		}
+		flushSep()
+		word.WriteRune(r)
+	}
+	flushWord()
+	flushSep()
+
+	return tokens
+}
+
+func (t *PunctuationTokenizer) Join(tokens []string) string {
+	return strings.Join(tokens, "")
+}
+
+func (t *PunctuationTokenizer) Name() string {
+	return "punctuation"
+}